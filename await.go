@@ -0,0 +1,143 @@
+package fmart
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// ErrInvoiceExpired is returned by AwaitInvoiceState when an invoice's
+// Expiry passes before it reaches one of the awaited terminal states.
+var ErrInvoiceExpired = errors.New("fmart: invoice expired before reaching a terminal state")
+
+// AwaitOptions configures AwaitInvoiceState's re-check backoff and the
+// states it waits for.
+type AwaitOptions struct {
+	// InitialInterval is the delay before the first re-check. Defaults to 1s.
+	InitialInterval time.Duration
+	// MaxInterval caps the delay between re-checks. Defaults to 30s.
+	MaxInterval time.Duration
+	// Multiplier scales the interval after each re-check. Defaults to 2.
+	Multiplier float64
+	// Jitter is the fraction, in [0, 1), of the interval randomly added or
+	// subtracted before each wait, to avoid synchronized wakeups across
+	// processes. Defaults to 0.1 (±10%).
+	Jitter float64
+	// TerminalStates are the states AwaitInvoiceState returns on. Defaults
+	// to StateDepositMade, StateDepositCanceled and StateDepositFinalized.
+	TerminalStates []InvoiceState
+	// Expiry, when set, makes AwaitInvoiceState give up with
+	// ErrInvoiceExpired once it passes.
+	Expiry time.Time
+}
+
+func (o AwaitOptions) initialInterval() time.Duration {
+	if o.InitialInterval > 0 {
+		return o.InitialInterval
+	}
+	return time.Second
+}
+
+func (o AwaitOptions) maxInterval() time.Duration {
+	if o.MaxInterval > 0 {
+		return o.MaxInterval
+	}
+	return 30 * time.Second
+}
+
+func (o AwaitOptions) multiplier() float64 {
+	if o.Multiplier > 0 {
+		return o.Multiplier
+	}
+	return 2
+}
+
+func (o AwaitOptions) jitter() float64 {
+	if o.Jitter > 0 {
+		return o.Jitter
+	}
+	return 0.1
+}
+
+func (o AwaitOptions) terminalStates() []InvoiceState {
+	if len(o.TerminalStates) > 0 {
+		return o.TerminalStates
+	}
+	return []InvoiceState{StateDepositMade, StateDepositCanceled, StateDepositFinalized}
+}
+
+func (o AwaitOptions) isTerminal(state InvoiceState) bool {
+	for _, s := range o.terminalStates() {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// AwaitInvoiceState blocks until id's locally-tracked InvoiceState in c.Store
+// reaches one of opts.TerminalStates, ctx is canceled, or opts.Expiry
+// passes, re-checking c.Store on an exponential backoff schedule. Every
+// observed transition is emitted through the same subscriber bus as
+// StatusNotificationHandler.
+//
+// AwaitInvoiceState does not contact FamilyMart: the invoice API exposes no
+// status-query endpoint, so c.Store only ever advances when something else
+// writes to it — in practice, StatusNotificationHandler handling the
+// webhook, or a caller driving c.Store from its own out-of-band
+// reconciliation. Deployments that can't host an inbound webhook still need
+// one of those to learn a real status; AwaitInvoiceState only lets callers
+// block on and fan out a Store's transitions instead of polling it by hand.
+func (c *Client) AwaitInvoiceState(ctx context.Context, id string, opts AwaitOptions) (InvoiceState, error) {
+	if c.Store == nil {
+		return 0, errors.New("fmart: AwaitInvoiceState requires a Client.Store")
+	}
+
+	var last InvoiceState
+	haveLast := false
+	interval := opts.initialInterval()
+
+	for {
+		state, err := c.Store.Get(id)
+		if err != nil && err != ErrInvoiceNotFound {
+			return 0, err
+		}
+
+		if err == nil && (!haveLast || state != last) {
+			last, haveLast = state, true
+
+			if status := statusForState(state); status != 0 {
+				c.notifySubscribers(ctx, []*InvoiceStatus{{ID: id, Status: status, UpdatedAt: time.Now()}})
+			}
+
+			if opts.isTerminal(state) {
+				return state, nil
+			}
+		}
+
+		if !opts.Expiry.IsZero() && time.Now().After(opts.Expiry) {
+			return 0, ErrInvoiceExpired
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(jitterDuration(interval, opts.jitter())):
+		}
+
+		interval = time.Duration(float64(interval) * opts.multiplier())
+		if max := opts.maxInterval(); interval > max {
+			interval = max
+		}
+	}
+}
+
+// jitterDuration returns d randomly adjusted by up to ±fraction.
+func jitterDuration(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	delta := (rand.Float64()*2 - 1) * fraction
+	return time.Duration(float64(d) * (1 + delta))
+}
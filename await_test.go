@@ -0,0 +1,85 @@
+package fmart
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClientAwaitInvoiceState(t *testing.T) {
+	store := NewMemoryStore()
+	store.Put("invoice-1", StateIssued)
+
+	c := &Client{Store: store}
+
+	var observed []int
+	c.Subscribe(func(ctx context.Context, s *InvoiceStatus) error {
+		observed = append(observed, s.Status)
+		return nil
+	})
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		store.Transition("invoice-1", StateIssued, StateDepositMade)
+		time.Sleep(5 * time.Millisecond)
+		store.Transition("invoice-1", StateDepositMade, StateDepositFinalized)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	state, err := c.AwaitInvoiceState(ctx, "invoice-1", AwaitOptions{
+		InitialInterval: time.Millisecond,
+		MaxInterval:     5 * time.Millisecond,
+		TerminalStates:  []InvoiceState{StateDepositFinalized},
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if state != StateDepositFinalized {
+		t.Errorf("expected StateDepositFinalized, got: %v", state)
+	}
+	if n := len(observed); n != 2 {
+		t.Fatalf("expected 2 observed statuses, got: %d", n)
+	}
+	if observed[0] != StatusDepositMade || observed[1] != StatusDepositFinalized {
+		t.Errorf("expected [StatusDepositMade, StatusDepositFinalized], got: %v", observed)
+	}
+}
+
+func TestClientAwaitInvoiceStateExpiry(t *testing.T) {
+	store := NewMemoryStore()
+	store.Put("invoice-1", StateIssued)
+
+	c := &Client{Store: store}
+
+	_, err := c.AwaitInvoiceState(context.Background(), "invoice-1", AwaitOptions{
+		InitialInterval: time.Millisecond,
+		Expiry:          time.Now().Add(-time.Second),
+	})
+	if err != ErrInvoiceExpired {
+		t.Errorf("expected ErrInvoiceExpired, got: %v", err)
+	}
+}
+
+func TestClientAwaitInvoiceStateContextCanceled(t *testing.T) {
+	store := NewMemoryStore()
+	store.Put("invoice-1", StateIssued)
+
+	c := &Client{Store: store}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.AwaitInvoiceState(ctx, "invoice-1", AwaitOptions{InitialInterval: time.Millisecond})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestClientAwaitInvoiceStateRequiresStore(t *testing.T) {
+	c := &Client{}
+	if _, err := c.AwaitInvoiceState(context.Background(), "invoice-1", AwaitOptions{}); err == nil {
+		t.Errorf("expected error when Store is nil, got nil")
+	}
+}
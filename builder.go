@@ -0,0 +1,174 @@
+package fmart
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"time"
+)
+
+// errOverflow is returned internally by mulUint64/addUint64; callers
+// translate it into a *ValidationError naming the overflowing field.
+var errOverflow = errors.New("fmart: uint64 overflow")
+
+// LineItem represents a single line of an itemized invoice. VAT is
+// expressed in thousandths of a percent, so a 10% consumption tax is
+// represented as 10000.
+type LineItem struct {
+	Title     string
+	Count     uint64
+	UnitPrice uint64
+	VAT       uint64
+}
+
+// LineItemBreakdown is a LineItem together with its computed totals, as
+// returned by InvoiceBuilder.Breakdown.
+type LineItemBreakdown struct {
+	LineItem
+
+	// TotalNet is UnitPrice*Count, before tax.
+	TotalNet uint64
+	// Tax is the VAT amount added on top of TotalNet, rounded to the
+	// nearest yen.
+	Tax uint64
+	// Total is TotalNet+Tax.
+	Total uint64
+}
+
+// InvoiceBuilder itemizes an invoice into LineItems and derives the single
+// Amount that IssueInvoice expects, so callers don't have to compute totals
+// and VAT by hand before every call.
+type InvoiceBuilder struct {
+	Name         string
+	NameKatakana string
+	PhoneNumber  string
+	Expiry       time.Time
+
+	items []LineItem
+}
+
+// NewInvoiceBuilder returns an InvoiceBuilder for the invoice recipient described by
+// name, nameKatakana and phoneNumber, expiring at expiry.
+func NewInvoiceBuilder(name, nameKatakana, phoneNumber string, expiry time.Time) *InvoiceBuilder {
+	return &InvoiceBuilder{
+		Name:         name,
+		NameKatakana: nameKatakana,
+		PhoneNumber:  phoneNumber,
+		Expiry:       expiry,
+	}
+}
+
+// AddItem appends a line item and returns the InvoiceBuilder so calls can
+// be chained.
+func (b *InvoiceBuilder) AddItem(title string, count, unitPrice, vat uint64) *InvoiceBuilder {
+	b.items = append(b.items, LineItem{
+		Title:     title,
+		Count:     count,
+		UnitPrice: unitPrice,
+		VAT:       vat,
+	})
+	return b
+}
+
+// Breakdown returns the line items together with their computed TotalNet,
+// Tax and Total, in the order they were added. It returns a
+// *ValidationError if any line item's totals overflow a uint64, rather than
+// silently reporting a wrapped, corrupted number.
+func (b *InvoiceBuilder) Breakdown() ([]LineItemBreakdown, error) {
+	breakdown := make([]LineItemBreakdown, len(b.items))
+
+	for i, item := range b.items {
+		totalNet, err := mulUint64(item.UnitPrice, item.Count)
+		if err != nil {
+			return nil, &ValidationError{Errors: map[string][]string{
+				fmt.Sprintf("items[%d]", i): {"unit price times count overflows a uint64"},
+			}}
+		}
+
+		taxBase, err := mulUint64(totalNet, item.VAT)
+		if err != nil {
+			return nil, &ValidationError{Errors: map[string][]string{
+				fmt.Sprintf("items[%d]", i): {"total net times VAT overflows a uint64"},
+			}}
+		}
+		tax := roundDivUint64(taxBase, 100000)
+
+		total, err := addUint64(totalNet, tax)
+		if err != nil {
+			return nil, &ValidationError{Errors: map[string][]string{
+				fmt.Sprintf("items[%d]", i): {"total net plus tax overflows a uint64"},
+			}}
+		}
+
+		breakdown[i] = LineItemBreakdown{
+			LineItem: item,
+			TotalNet: totalNet,
+			Tax:      tax,
+			Total:    total,
+		}
+	}
+
+	return breakdown, nil
+}
+
+// Build sums the line items added via AddItem into a single Amount and
+// returns the resulting IssueInvoiceParams. It returns a *ValidationError
+// when a line item overflows, or when the summed total falls outside the
+// range IssueInvoice accepts.
+func (b *InvoiceBuilder) Build() (*IssueInvoiceParams, error) {
+	breakdown, err := b.Breakdown()
+	if err != nil {
+		return nil, err
+	}
+
+	var total uint64
+	for _, item := range breakdown {
+		total, err = addUint64(total, item.Total)
+		if err != nil {
+			return nil, &ValidationError{Errors: map[string][]string{
+				"amount": {"summed total overflows a uint64"},
+			}}
+		}
+	}
+
+	errs := make(map[string][]string)
+	applyValidations(errs, "amount", int(total), amountValidations)
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+
+	return &IssueInvoiceParams{
+		Name:         b.Name,
+		NameKatakana: b.NameKatakana,
+		PhoneNumber:  b.PhoneNumber,
+		Amount:       int(total),
+		Expiry:       b.Expiry,
+	}, nil
+}
+
+// roundDivUint64 divides num by den, rounding half up.
+func roundDivUint64(num, den uint64) uint64 {
+	q, r := num/den, num%den
+	if r >= den-den/2 {
+		q++
+	}
+	return q
+}
+
+// mulUint64 returns a*b, or an error if the product overflows a uint64.
+func mulUint64(a, b uint64) (uint64, error) {
+	hi, lo := bits.Mul64(a, b)
+	if hi != 0 {
+		return 0, errOverflow
+	}
+	return lo, nil
+}
+
+// addUint64 returns a+b, or an error if the sum overflows a uint64.
+func addUint64(a, b uint64) (uint64, error) {
+	sum, carry := bits.Add64(a, b, 0)
+	if carry != 0 {
+		return 0, errOverflow
+	}
+	return sum, nil
+}
@@ -0,0 +1,75 @@
+package fmart
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInvoiceBuilderBuild(t *testing.T) {
+	b := NewInvoiceBuilder("松本行弘", "マツモトヒロユキ", "0120-444-444", time.Now().AddDate(0, 0, 1))
+	b.AddItem("apple", 2, 100, 10000)
+	b.AddItem("box", 1, 50, 0)
+
+	p, err := b.Build()
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+
+	// apple: 200 net + 10% tax (20) = 220
+	// box: 50 net + 0% tax = 50
+	if e := 270; p.Amount != e {
+		t.Errorf("expected amount %d, got: %d", e, p.Amount)
+	}
+}
+
+func TestInvoiceBuilderBreakdown(t *testing.T) {
+	b := NewInvoiceBuilder("松本行弘", "マツモトヒロユキ", "0120-444-444", time.Now().AddDate(0, 0, 1))
+	b.AddItem("apple", 3, 100, 10000)
+
+	breakdown, err := b.Breakdown()
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if n := len(breakdown); n != 1 {
+		t.Fatalf("expected 1 item, got: %d", n)
+	}
+
+	item := breakdown[0]
+	if item.TotalNet != 300 {
+		t.Errorf("expected TotalNet 300, got: %d", item.TotalNet)
+	}
+	if item.Tax != 30 {
+		t.Errorf("expected Tax 30, got: %d", item.Tax)
+	}
+	if item.Total != 330 {
+		t.Errorf("expected Total 330, got: %d", item.Total)
+	}
+}
+
+func TestInvoiceBuilderBuildInvalidAmount(t *testing.T) {
+	b := NewInvoiceBuilder("松本行弘", "マツモトヒロユキ", "0120-444-444", time.Now().AddDate(0, 0, 1))
+	b.AddItem("too expensive", 1, 1000000, 0)
+
+	if _, err := b.Build(); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("expected ErrInvalidParams error, got: %v", err)
+	}
+}
+
+func TestInvoiceBuilderBreakdownOverflow(t *testing.T) {
+	b := NewInvoiceBuilder("松本行弘", "マツモトヒロユキ", "0120-444-444", time.Now().AddDate(0, 0, 1))
+	b.AddItem("huge", 1, 1<<63, 200000)
+
+	if _, err := b.Breakdown(); err == nil {
+		t.Errorf("expected an overflow error, got nil")
+	}
+}
+
+func TestInvoiceBuilderBuildOverflow(t *testing.T) {
+	b := NewInvoiceBuilder("松本行弘", "マツモトヒロユキ", "0120-444-444", time.Now().AddDate(0, 0, 1))
+	b.AddItem("huge", 1, 1<<63, 200000)
+
+	if _, err := b.Build(); err == nil {
+		t.Errorf("expected an overflow error, got nil")
+	}
+}
@@ -0,0 +1,576 @@
+package fmart
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultClient is the Client used by the package-level functions below. Its
+// zero value falls back to the package-level APIEndpoint, UserID and
+// UserPassword variables and http.DefaultClient, so existing callers that
+// only ever touched the globals keep working unmodified.
+var DefaultClient = &Client{}
+
+// Client is a FamilyMart invoice API client. Unlike the package-level
+// functions, a Client carries its own endpoint, credentials and HTTP
+// transport, which makes it possible to drive multiple issuer accounts from
+// the same process and to exercise the client in tests without mutating
+// package globals.
+//
+// The zero value of Client is ready to use: any field left unset falls back
+// to the corresponding package-level variable (APIEndpoint, UserID,
+// UserPassword) or http.DefaultClient.
+type Client struct {
+	// Endpoint is URL of FamilyMart Invoice API. Falls back to APIEndpoint
+	// when empty.
+	Endpoint string
+	// UserID is ID of the invoice issuer. Falls back to UserID when empty.
+	UserID string
+	// UserPassword is password of the invoice issuer. Falls back to
+	// UserPassword when empty.
+	UserPassword string
+	// HTTPClient performs requests. Falls back to http.DefaultClient when
+	// nil. Set its Transport to a custom http.RoundTripper to intercept
+	// requests in tests.
+	HTTPClient *http.Client
+	// Logger, when set, receives a line for every retried request.
+	Logger *log.Logger
+	// MaxRetries is the number of additional attempts made after a request
+	// fails with a 5xx response. Defaults to 0 (no retries).
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry. It doubles after
+	// each subsequent attempt. Defaults to 100ms.
+	RetryBackoff time.Duration
+	// Store, when set, is driven through the invoice lifecycle by
+	// IssueInvoice, ModifyInvoice, CancelInvoice and ParseInvoiceStatuses,
+	// so callers have a single source of truth for invoice state instead
+	// of manually correlating IDs with status callbacks. Left nil, a
+	// Client tracks no state, matching its previous behavior.
+	Store Store
+	// SigningSecret, when set, makes ParseInvoiceStatuses call
+	// VerifyCallback to authenticate the incoming request via HMAC
+	// signature instead of relying solely on the plaintext
+	// login_user_id/login_password fields.
+	SigningSecret string
+	// SignatureHeader is the header VerifyCallback reads the HMAC
+	// signature from. Defaults to "X-Fmart-Signature".
+	SignatureHeader string
+	// TimestampHeader is the header VerifyCallback reads the signed
+	// timestamp from. Defaults to "X-Fmart-Timestamp".
+	TimestampHeader string
+	// SkewWindow bounds how far VerifyCallback allows the timestamp
+	// header to drift from the current time. Defaults to 5 minutes.
+	SkewWindow time.Duration
+	// SubscriberTimeout bounds how long a single subscriber registered via
+	// Subscribe may take to process a single status. Zero means no
+	// timeout. Shared by StatusNotificationHandler and AwaitInvoiceState,
+	// so both integration modes deliver to subscribers the same way.
+	SubscriberTimeout time.Duration
+
+	subscribersMu sync.Mutex
+	subscribers   map[int]StatusSubscriber
+	nextSubID     int
+}
+
+// StatusSubscriber receives a single observed InvoiceStatus, whether it
+// came from the StatusNotificationHandler webhook or from
+// AwaitInvoiceState. Returning a non-nil error marks the delivery as
+// failed.
+type StatusSubscriber func(context.Context, *InvoiceStatus) error
+
+// Subscribe registers fn to be called with every status the Client
+// observes, via either StatusNotificationHandler or AwaitInvoiceState. The
+// returned unsubscribe function removes fn; it is safe to call more than
+// once.
+func (c *Client) Subscribe(fn StatusSubscriber) (unsubscribe func()) {
+	c.subscribersMu.Lock()
+	defer c.subscribersMu.Unlock()
+
+	if c.subscribers == nil {
+		c.subscribers = make(map[int]StatusSubscriber)
+	}
+
+	id := c.nextSubID
+	c.nextSubID++
+	c.subscribers[id] = fn
+
+	return func() {
+		c.subscribersMu.Lock()
+		defer c.subscribersMu.Unlock()
+		delete(c.subscribers, id)
+	}
+}
+
+func (c *Client) snapshotSubscribers() []StatusSubscriber {
+	c.subscribersMu.Lock()
+	defer c.subscribersMu.Unlock()
+
+	subs := make([]StatusSubscriber, 0, len(c.subscribers))
+	for _, fn := range c.subscribers {
+		subs = append(subs, fn)
+	}
+	return subs
+}
+
+// notifySubscribers delivers every status to every subscriber, recording
+// failures rather than stopping at the first one, so a slow or failing
+// subscriber doesn't prevent the others from observing the batch.
+func (c *Client) notifySubscribers(ctx context.Context, statuses []*InvoiceStatus) error {
+	subs := c.snapshotSubscribers()
+
+	var failures int
+	var firstErr error
+
+	for _, s := range statuses {
+		for _, sub := range subs {
+			subCtx := ctx
+			var cancel context.CancelFunc
+			if c.SubscriberTimeout > 0 {
+				subCtx, cancel = context.WithTimeout(ctx, c.SubscriberTimeout)
+			}
+			err := sub(subCtx, s)
+			if cancel != nil {
+				cancel()
+			}
+
+			if err != nil {
+				c.logf("fmart: subscriber failed for invoice %s: %v", s.ID, err)
+				failures++
+				if firstErr == nil {
+					firstErr = fmt.Errorf("invoice %s: %w", s.ID, err)
+				}
+			}
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("fmart: %d subscriber deliveries failed, first error: %v", failures, firstErr)
+	}
+	return nil
+}
+
+func (c *Client) endpoint() string {
+	if c.Endpoint != "" {
+		return c.Endpoint
+	}
+	return APIEndpoint
+}
+
+func (c *Client) userID() string {
+	if c.UserID != "" {
+		return c.UserID
+	}
+	return UserID
+}
+
+func (c *Client) userPassword() string {
+	if c.UserPassword != "" {
+		return c.UserPassword
+	}
+	return UserPassword
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) retryBackoff() time.Duration {
+	if c.RetryBackoff > 0 {
+		return c.RetryBackoff
+	}
+	return 100 * time.Millisecond
+}
+
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Printf(format, args...)
+	}
+}
+
+// withAuth overrides the login_user_id and login_password fields of v with
+// the Client's own credentials.
+func (c *Client) withAuth(v url.Values) url.Values {
+	v.Set("login_user_id", c.userID())
+	v.Set("login_password", c.userPassword())
+	return v
+}
+
+// IssueInvoice issues a new invoice. Returns invoice identifier when success.
+func (c *Client) IssueInvoice(p *IssueInvoiceParams) (string, error) {
+	return c.IssueInvoiceContext(context.Background(), p)
+}
+
+// IssueInvoiceContext is like IssueInvoice but honors ctx for cancellation
+// and deadlines.
+func (c *Client) IssueInvoiceContext(ctx context.Context, p *IssueInvoiceParams) (string, error) {
+	if errs := p.Errors(); len(errs) > 0 {
+		return "", &ValidationError{Errors: errs}
+	}
+
+	id, err := c.requestContext(ctx, c.withAuth(p.Params()))
+	if err != nil {
+		return "", err
+	}
+
+	if c.Store != nil {
+		if err := c.Store.Put(id, StateIssued); err != nil {
+			return id, err
+		}
+	}
+
+	return id, nil
+}
+
+// ModifyInvoice takes ID of existing invoice and modifies it.
+func (c *Client) ModifyInvoice(p *ModifyInvoiceParams) error {
+	return c.ModifyInvoiceContext(context.Background(), p)
+}
+
+// ModifyInvoiceContext is like ModifyInvoice but honors ctx for
+// cancellation and deadlines.
+func (c *Client) ModifyInvoiceContext(ctx context.Context, p *ModifyInvoiceParams) error {
+	if errs := p.Errors(); len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+
+	var from InvoiceState
+	if c.Store != nil {
+		var err error
+		from, err = c.Store.Get(p.ID)
+		if err != nil {
+			return err
+		}
+		if !validTransition(from, StateModified) {
+			return &TransitionError{ID: p.ID, From: from, To: StateModified}
+		}
+	}
+
+	if _, err := c.requestContext(ctx, c.withAuth(p.Params())); err != nil {
+		return err
+	}
+
+	if c.Store != nil {
+		return c.Store.Transition(p.ID, from, StateModified)
+	}
+	return nil
+}
+
+// CancelInvoice takes ID of existing invoice and cancels it.
+func (c *Client) CancelInvoice(ID string) error {
+	return c.CancelInvoiceContext(context.Background(), ID)
+}
+
+// CancelInvoiceContext is like CancelInvoice but honors ctx for
+// cancellation and deadlines.
+func (c *Client) CancelInvoiceContext(ctx context.Context, ID string) error {
+	var from InvoiceState
+	if c.Store != nil {
+		var err error
+		from, err = c.Store.Get(ID)
+		if err != nil {
+			return err
+		}
+		if !validTransition(from, StateCanceled) {
+			return &TransitionError{ID: ID, From: from, To: StateCanceled}
+		}
+	}
+
+	v := c.withAuth(url.Values{
+		"regist_type": {"9"},
+		"receipt_no":  {ID},
+	})
+
+	if _, err := c.requestContext(ctx, v); err != nil {
+		return err
+	}
+
+	if c.Store != nil {
+		return c.Store.Transition(ID, from, StateCanceled)
+	}
+	return nil
+}
+
+// AckInvoiceStatuses takes array of invoice IDs and sends acknowledgement request.
+func (c *Client) AckInvoiceStatuses(IDs []string) error {
+	return c.AckInvoiceStatusesContext(context.Background(), IDs)
+}
+
+// AckInvoiceStatusesContext is like AckInvoiceStatuses but honors ctx for
+// cancellation and deadlines.
+func (c *Client) AckInvoiceStatusesContext(ctx context.Context, IDs []string) error {
+	body := []byte(strings.Join(IDs, idDelimiter))
+
+	res, err := c.doContext(ctx, "text/plain", body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return &APIError{StatusCode: res.StatusCode}
+	}
+
+	return nil
+}
+
+// ParseInvoiceStatuses takes *http.Request, parses it and returns statuses of
+// existing invlices. It returns an error when one or more statuses contains
+// invalid data. When c.Store is set, each status also drives the store to
+// the matching InvoiceState; a status whose invoice isn't tracked or whose
+// transition is illegal does not fail the call, but is reported on that
+// status's InvoiceStatus.StoreError.
+func (c *Client) ParseInvoiceStatuses(r *http.Request) ([]*InvoiceStatus, error) {
+	return c.ParseInvoiceStatusesContext(context.Background(), r)
+}
+
+// ParseInvoiceStatusesContext is like ParseInvoiceStatuses but accepts ctx
+// so callers can thread request-scoped values and deadlines through to
+// future extensions of the verification logic.
+func (c *Client) ParseInvoiceStatusesContext(ctx context.Context, r *http.Request) ([]*InvoiceStatus, error) {
+	if c.SigningSecret != "" {
+		if err := c.VerifyCallback(r); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.FormValue("login_user_id") != c.userID() ||
+		r.FormValue("login_password") != c.userPassword() {
+		return nil, ErrUnauthorizedRequest
+	}
+
+	n, err := strconv.Atoi(r.FormValue("number_of_notify"))
+	if err != nil {
+		return nil, ErrInvalidRequest
+	}
+
+	statuses := make([]*InvoiceStatus, n)
+
+	for i := 0; i < n; i++ {
+		s, err := parseInvoiceStatusAt(r, i)
+		if err != nil {
+			return nil, ErrInvalidRequest
+		}
+
+		statuses[i] = s
+	}
+
+	if c.Store != nil {
+		for _, s := range statuses {
+			c.applyStatusTransition(s)
+		}
+	}
+
+	return statuses, nil
+}
+
+// applyStatusTransition drives c.Store to the state implied by s. It does
+// not fail the whole notification when s refers to an invoice the store
+// doesn't recognize or can't legally reach that state from: it logs the
+// failure and also records it on s.StoreError, so a caller that needs to
+// know which individual statuses didn't sync isn't limited to the logger.
+func (c *Client) applyStatusTransition(s *InvoiceStatus) {
+	to := stateForStatus(s.Status)
+
+	from, err := c.Store.Get(s.ID)
+	if err != nil {
+		c.logf("fmart: ignoring status for untracked invoice %s: %v", s.ID, err)
+		s.StoreError = err
+		return
+	}
+
+	if err := c.Store.Transition(s.ID, from, to); err != nil {
+		c.logf("fmart: ignoring illegal transition for invoice %s: %v", s.ID, err)
+		s.StoreError = err
+	}
+}
+
+// stateForStatus maps the status reported by a deposit notification to the
+// InvoiceState it drives the Store to.
+func stateForStatus(status int) InvoiceState {
+	switch status {
+	case StatusDepositMade:
+		return StateDepositMade
+	case StatusDepositCanceled:
+		return StateDepositCanceled
+	case StatusDepositFinalized:
+		return StateDepositFinalized
+	default:
+		return -1
+	}
+}
+
+// statusForState is the inverse of stateForStatus, used by
+// AwaitInvoiceState to report a Store-observed InvoiceState through the
+// same InvoiceStatus shape the webhook handler delivers to subscribers.
+// Returns 0, the zero value of none of the Status* constants, for states
+// that don't correspond to a deposit status.
+func statusForState(state InvoiceState) int {
+	switch state {
+	case StateDepositMade:
+		return StatusDepositMade
+	case StateDepositCanceled:
+		return StatusDepositCanceled
+	case StateDepositFinalized:
+		return StatusDepositFinalized
+	default:
+		return 0
+	}
+}
+
+// requestContext encodes p as Shift-JIS, posts it to the endpoint (retrying
+// on 5xx responses) and decodes the single-line invoice ID or multi-line
+// error message out of the Shift-JIS response body.
+func (c *Client) requestContext(ctx context.Context, p url.Values) (string, error) {
+	body, err := ioutil.ReadAll(encodeShiftJIS(strings.NewReader(p.Encode())))
+	if err != nil {
+		return "", fmt.Errorf("fmart: %v", err)
+	}
+
+	res, err := c.doContext(ctx, "application/x-www-form-urlencoded", body)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(decodeShiftJIS(res.Body))
+		return "", &APIError{StatusCode: res.StatusCode, RawBody: string(body)}
+	}
+
+	respBody, err := ioutil.ReadAll(decodeShiftJIS(res.Body))
+	if err != nil {
+		return "", errors.New("fmart: could not read response body")
+	}
+
+	lines := strings.Split(string(respBody), "\n")
+	if len(lines) == 1 {
+		return lines[0], nil
+	}
+	return "", parseAPIError(0, string(respBody))
+}
+
+// doContext posts body to the endpoint, retrying up to c.MaxRetries times
+// with exponential backoff whenever the server returns a 5xx response.
+//
+// Retries are scoped to 5xx responses only: a transport-level error from
+// c.httpClient().Do (a timeout, a connection reset, a canceled dial) is
+// returned immediately without retrying, since IssueInvoice/ModifyInvoice
+// mutate state server-side and a transport error doesn't tell us whether
+// the request actually reached FamilyMart before the response was lost.
+// Retrying in that case risks double-submission; a 5xx response, in
+// contrast, tells us FamilyMart itself rejected the attempt.
+func (c *Client) doContext(ctx context.Context, contentType string, body []byte) (*http.Response, error) {
+	backoff := c.retryBackoff()
+
+	var lastErr error
+	for attempt := 0; attempt <= c.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(), bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("fmart: %v", err)
+		}
+		req.Header.Set("Content-Type", contentType)
+
+		res, err := c.httpClient().Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("fmart: %v", err)
+		}
+
+		if res.StatusCode >= 500 {
+			res.Body.Close()
+			lastErr = &APIError{StatusCode: res.StatusCode}
+			if attempt < c.MaxRetries {
+				c.logf("fmart: retrying after server error (attempt %d): %d", attempt+1, res.StatusCode)
+			}
+			continue
+		}
+
+		return res, nil
+	}
+
+	return nil, lastErr
+}
+
+// IssueInvoice issues a new invoice using DefaultClient. Returns invoice
+// identifier when success.
+func IssueInvoice(p *IssueInvoiceParams) (string, error) {
+	return DefaultClient.IssueInvoice(p)
+}
+
+// IssueInvoiceContext is like IssueInvoice but honors ctx for cancellation
+// and deadlines.
+func IssueInvoiceContext(ctx context.Context, p *IssueInvoiceParams) (string, error) {
+	return DefaultClient.IssueInvoiceContext(ctx, p)
+}
+
+// ModifyInvoice takes ID of existing invoice and modifies it using
+// DefaultClient.
+func ModifyInvoice(p *ModifyInvoiceParams) error {
+	return DefaultClient.ModifyInvoice(p)
+}
+
+// ModifyInvoiceContext is like ModifyInvoice but honors ctx for
+// cancellation and deadlines.
+func ModifyInvoiceContext(ctx context.Context, p *ModifyInvoiceParams) error {
+	return DefaultClient.ModifyInvoiceContext(ctx, p)
+}
+
+// CancelInvoice takes ID of existing invoice and cancels it using
+// DefaultClient.
+func CancelInvoice(ID string) error {
+	return DefaultClient.CancelInvoice(ID)
+}
+
+// CancelInvoiceContext is like CancelInvoice but honors ctx for
+// cancellation and deadlines.
+func CancelInvoiceContext(ctx context.Context, ID string) error {
+	return DefaultClient.CancelInvoiceContext(ctx, ID)
+}
+
+// AckInvoiceStatuses takes array of invoice IDs and sends acknowledgement
+// request using DefaultClient.
+func AckInvoiceStatuses(IDs []string) error {
+	return DefaultClient.AckInvoiceStatuses(IDs)
+}
+
+// AckInvoiceStatusesContext is like AckInvoiceStatuses but honors ctx for
+// cancellation and deadlines.
+func AckInvoiceStatusesContext(ctx context.Context, IDs []string) error {
+	return DefaultClient.AckInvoiceStatusesContext(ctx, IDs)
+}
+
+// ParseInvoiceStatuses takes *http.Request, parses it and returns statuses
+// of existing invlices using DefaultClient. It returns an error when one or
+// more statuses contains invalid data.
+func ParseInvoiceStatuses(r *http.Request) ([]*InvoiceStatus, error) {
+	return DefaultClient.ParseInvoiceStatuses(r)
+}
+
+// ParseInvoiceStatusesContext is like ParseInvoiceStatuses but honors ctx.
+func ParseInvoiceStatusesContext(ctx context.Context, r *http.Request) ([]*InvoiceStatus, error) {
+	return DefaultClient.ParseInvoiceStatusesContext(ctx, r)
+}
@@ -0,0 +1,243 @@
+package fmart
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientStoreTracksLifecycle(t *testing.T) {
+	var res string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, res)
+	}))
+	defer ts.Close()
+
+	c := &Client{Endpoint: ts.URL, Store: NewMemoryStore()}
+
+	res = "invoice-1234"
+	p := &IssueInvoiceParams{
+		Name:         "松本行弘",
+		NameKatakana: "マツモトヒロユキ",
+		PhoneNumber:  "0120-444-444",
+		Amount:       100,
+		Expiry:       time.Now().AddDate(0, 0, 1),
+	}
+	id, err := c.IssueInvoice(p)
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+
+	if state, _ := c.Store.Get(id); state != StateIssued {
+		t.Errorf("expected StateIssued, got: %v", state)
+	}
+
+	mp := &ModifyInvoiceParams{
+		ID:           id,
+		Name:         p.Name,
+		NameKatakana: p.NameKatakana,
+		PhoneNumber:  p.PhoneNumber,
+		Amount:       200,
+		Expiry:       p.Expiry,
+	}
+	if err := c.ModifyInvoice(mp); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if state, _ := c.Store.Get(id); state != StateModified {
+		t.Errorf("expected StateModified, got: %v", state)
+	}
+
+	c.Store.Transition(id, StateModified, StateDepositMade)
+	c.Store.Transition(id, StateDepositMade, StateDepositFinalized)
+
+	if err := c.CancelInvoice(id); err == nil {
+		t.Errorf("expected error canceling a finalized invoice, got nil")
+	}
+}
+
+func TestClientParseInvoiceStatusesSurfacesStoreErrors(t *testing.T) {
+	store := NewMemoryStore()
+	store.Put("invoice-tracked", StateIssued)
+
+	c := &Client{Store: store}
+
+	v := url.Values{
+		"login_user_id":     {""},
+		"login_password":    {""},
+		"number_of_notify":  {"2"},
+		"receipt_no_0000":   {"invoice-tracked"},
+		"status_0000":       {"1"},
+		"receipt_date_0000": {"201502082010"},
+		"payment_0000":      {"100"},
+		"receipt_no_0001":   {"invoice-unknown"},
+		"status_0001":       {"1"},
+		"receipt_date_0001": {"201502082010"},
+		"payment_0001":      {"100"},
+	}
+	r, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(v.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	statuses, err := c.ParseInvoiceStatuses(r)
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if n := len(statuses); n != 2 {
+		t.Fatalf("expected 2 statuses, got: %d", n)
+	}
+
+	if statuses[0].StoreError != nil {
+		t.Errorf("expected nil StoreError for tracked invoice, got: %v", statuses[0].StoreError)
+	}
+	if state, _ := c.Store.Get("invoice-tracked"); state != StateDepositMade {
+		t.Errorf("expected StateDepositMade, got: %v", state)
+	}
+
+	if statuses[1].StoreError == nil {
+		t.Errorf("expected StoreError for untracked invoice, got nil")
+	}
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper, so tests can
+// intercept requests without starting a real listener.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestClientDoContextDoesNotRetryTransportErrors(t *testing.T) {
+	var attempts int32
+	errTransport := errors.New("connection reset by peer")
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&attempts, 1)
+		return nil, errTransport
+	})
+
+	c := &Client{
+		Endpoint:     "http://example.invalid",
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+		HTTPClient:   &http.Client{Transport: rt},
+	}
+
+	_, err := c.doContext(context.Background(), "application/x-www-form-urlencoded", []byte("x=1"))
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Errorf("expected doContext not to retry a transport error, got %d attempts", n)
+	}
+}
+
+func TestClientDoContextRetriesOnServerError(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, "invoice-1234")
+	}))
+	defer ts.Close()
+
+	c := &Client{
+		Endpoint:     ts.URL,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	}
+
+	res, err := c.doContext(context.Background(), "application/x-www-form-urlencoded", []byte("x=1"))
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	defer res.Body.Close()
+
+	if n := atomic.LoadInt32(&attempts); n != 3 {
+		t.Errorf("expected 3 attempts, got: %d", n)
+	}
+}
+
+func TestClientDoContextGivesUpAfterMaxRetries(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	c := &Client{
+		Endpoint:     ts.URL,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	}
+
+	_, err := c.doContext(context.Background(), "application/x-www-form-urlencoded", []byte("x=1"))
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if n := atomic.LoadInt32(&attempts); n != 3 {
+		t.Errorf("expected 3 attempts (1 initial + 2 retries), got: %d", n)
+	}
+}
+
+func TestClientDoContextUsesCustomRoundTripper(t *testing.T) {
+	var gotContentType string
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		gotContentType = r.Header.Get("Content-Type")
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader("invoice-1234")),
+			Header:     make(http.Header),
+		}, nil
+	})
+
+	c := &Client{
+		Endpoint:   "http://example.invalid",
+		HTTPClient: &http.Client{Transport: rt},
+	}
+
+	res, err := c.doContext(context.Background(), "application/x-www-form-urlencoded", []byte("x=1"))
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	defer res.Body.Close()
+
+	if gotContentType != "application/x-www-form-urlencoded" {
+		t.Errorf("expected request to go through the custom RoundTripper, got Content-Type: %q", gotContentType)
+	}
+}
+
+func TestClientDoContextReturnsPromptlyOnCanceledContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	c := &Client{
+		Endpoint:     ts.URL,
+		MaxRetries:   5,
+		RetryBackoff: time.Hour,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	_, err := c.doContext(ctx, "application/x-www-form-urlencoded", []byte("x=1"))
+	elapsed := time.Since(start)
+
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+	if elapsed > time.Second {
+		t.Errorf("expected doContext to return promptly on a canceled context, took: %v", elapsed)
+	}
+}
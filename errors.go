@@ -0,0 +1,80 @@
+package fmart
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError reports field-level validation failures, as produced by
+// (*IssueInvoiceParams).Errors, (*ModifyInvoiceParams).Errors and
+// (*InvoiceBuilder).Build. It wraps ErrInvalidParams so existing code using
+// errors.Is(err, ErrInvalidParams) keeps working.
+type ValidationError struct {
+	// Errors maps field name to the messages describing why it is
+	// invalid.
+	Errors map[string][]string
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("fmart: invalid params:")
+	for field, messages := range e.Errors {
+		for _, message := range messages {
+			fmt.Fprintf(&b, " %s %s;", field, message)
+		}
+	}
+	return strings.TrimSuffix(b.String(), ";")
+}
+
+// Unwrap lets errors.Is(err, ErrInvalidParams) succeed for a ValidationError.
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidParams
+}
+
+// Is reports whether target is ErrInvalidParams, so errors.Is(err,
+// ErrInvalidParams) succeeds without relying on Unwrap alone.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidParams
+}
+
+// Field returns the validation messages for the given field name, or nil
+// if the field has none.
+func (e *ValidationError) Field(name string) []string {
+	return e.Errors[name]
+}
+
+// APIError is returned when the FamilyMart API responds with a non-200 HTTP
+// status, or with a 200 whose body carries the "-1\n<message>" failure
+// format.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response. Zero when the
+	// failure was reported in-band via the "-1\n<message>" body format on
+	// an HTTP 200.
+	StatusCode int
+	// Code is the first line of a "-1\n<message>" body, e.g. "-1". Empty
+	// for a non-200 HTTP response with no such body.
+	Code string
+	// Message is the text following Code, e.g. "error message".
+	Message string
+	// RawBody is the response body as received, after Shift-JIS decoding.
+	RawBody string
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("fmart: api error %s: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("fmart: server returned %d", e.StatusCode)
+}
+
+// parseAPIError splits a failure response body into its code and message,
+// per the "-1\n<message>" format.
+func parseAPIError(statusCode int, rawBody string) *APIError {
+	code, message, _ := strings.Cut(rawBody, "\n")
+	return &APIError{
+		StatusCode: statusCode,
+		Code:       code,
+		Message:    message,
+		RawBody:    rawBody,
+	}
+}
@@ -0,0 +1,30 @@
+package fmart
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidationErrorIs(t *testing.T) {
+	err := &ValidationError{Errors: map[string][]string{"amount": {"must be greater than 1"}}}
+
+	if !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("expected errors.Is to match ErrInvalidParams")
+	}
+	if msgs := err.Field("amount"); len(msgs) != 1 {
+		t.Errorf("expected 1 message for amount, got: %d", len(msgs))
+	}
+	if msgs := err.Field("missing"); msgs != nil {
+		t.Errorf("expected nil for unknown field, got: %v", msgs)
+	}
+}
+
+func TestParseAPIError(t *testing.T) {
+	err := parseAPIError(0, "-1\nerror message")
+	if err.Code != "-1" {
+		t.Errorf("expected code -1, got: %s", err.Code)
+	}
+	if err.Message != "error message" {
+		t.Errorf("expected message 'error message', got: %s", err.Message)
+	}
+}
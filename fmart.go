@@ -6,12 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
-	"strings"
 	"time"
 
 	"golang.org/x/text/encoding/japanese"
@@ -28,11 +26,17 @@ var (
 )
 
 var (
-	// APIEndpoint is URL of FamilyMart Invoice API.
+	// APIEndpoint is URL of FamilyMart Invoice API. It is used by
+	// DefaultClient and, transitively, by the package-level functions below
+	// whenever a Client field is left empty.
 	APIEndpoint = "https://"
-	// UserID is ID of the invoice issuer.
+	// UserID is ID of the invoice issuer. It is used by DefaultClient and,
+	// transitively, by the package-level functions below whenever a Client
+	// field is left empty.
 	UserID = ""
-	// UserPassword is password of the invoice issuer.
+	// UserPassword is password of the invoice issuer. It is used by
+	// DefaultClient and, transitively, by the package-level functions below
+	// whenever a Client field is left empty.
 	UserPassword = ""
 )
 
@@ -97,7 +101,10 @@ func (p *IssueInvoiceParams) Errors() map[string][]string {
 	return errs
 }
 
-// Params returns url.Values representation of IssueInvoiceParams.
+// Params returns url.Values representation of IssueInvoiceParams. The
+// login_user_id and login_password fields reflect the package-level UserID
+// and UserPassword; a Client overrides them with its own credentials before
+// sending the request.
 func (p *IssueInvoiceParams) Params() url.Values {
 	return url.Values{
 		"login_user_id":  {UserID},
@@ -111,15 +118,6 @@ func (p *IssueInvoiceParams) Params() url.Values {
 	}
 }
 
-// IssueInvoice issues a new invoice. Returns invoice identifier when success.
-func IssueInvoice(p *IssueInvoiceParams) (string, error) {
-	if !p.IsValid() {
-		return "", ErrInvalidParams
-	}
-
-	return request(p.Params())
-}
-
 // ModifyInvoiceParams represents params for ModifyInvoice and provides validations.
 type ModifyInvoiceParams struct {
 	ID           string
@@ -150,7 +148,10 @@ func (p *ModifyInvoiceParams) Errors() map[string][]string {
 	return errs
 }
 
-// Params returns url.Values representation of ModifyInvoiceParams.
+// Params returns url.Values representation of ModifyInvoiceParams. The
+// login_user_id and login_password fields reflect the package-level UserID
+// and UserPassword; a Client overrides them with its own credentials before
+// sending the request.
 func (p *ModifyInvoiceParams) Params() url.Values {
 	return url.Values{
 		"login_user_id":  {UserID},
@@ -165,46 +166,8 @@ func (p *ModifyInvoiceParams) Params() url.Values {
 	}
 }
 
-// ModifyInvoice takes ID of existing invoice and modifies it.
-func ModifyInvoice(p *ModifyInvoiceParams) error {
-	if !p.IsValid() {
-		return ErrInvalidParams
-	}
-
-	_, err := request(p.Params())
-	return err
-}
-
-// CancelInvoice takes ID of existing invoice and cancels it.
-func CancelInvoice(ID string) error {
-	v := url.Values{
-		"login_user_id":  {UserID},
-		"login_password": {UserPassword},
-		"regist_type":    {"9"},
-		"receipt_no":     {ID},
-	}
-
-	_, err := request(v)
-	return err
-}
-
 const idDelimiter = "\r\n"
 
-// AckInvoiceStatuses takes array of invoice IDs and sends acknowledgement request.
-func AckInvoiceStatuses(IDs []string) error {
-	r := strings.NewReader(strings.Join(IDs, idDelimiter))
-	res, err := http.Post(APIEndpoint, "text/plain", r)
-	if err != nil {
-		return err
-	}
-
-	if res.StatusCode != 200 {
-		return errors.New("fmart: server returned non 200")
-	}
-
-	return nil
-}
-
 const (
 	// StatusDepositMade represents the situation where customer deposited but still be able to cancel.
 	StatusDepositMade = 1
@@ -220,34 +183,14 @@ type InvoiceStatus struct {
 	Amount    int
 	Status    int
 	UpdatedAt time.Time
-}
-
-// ParseInvoiceStatuses takes *http.Request, parses it and returns statuses of
-// existing invlices. It returns an error when one or more statuses contains
-// invalid data.
-func ParseInvoiceStatuses(r *http.Request) ([]*InvoiceStatus, error) {
-	if r.FormValue("login_user_id") != UserID ||
-		r.FormValue("login_password") != UserPassword {
-		return nil, ErrUnauthorizedRequest
-	}
-
-	n, err := strconv.Atoi(r.FormValue("number_of_notify"))
-	if err != nil {
-		return nil, ErrInvalidRequest
-	}
-
-	statuses := make([]*InvoiceStatus, n)
-
-	for i := 0; i < n; i++ {
-		s, err := parseInvoiceStatusAt(r, i)
-		if err != nil {
-			return nil, ErrInvalidRequest
-		}
-
-		statuses[i] = s
-	}
-
-	return statuses, nil
+	// StoreError is set by ParseInvoiceStatusesContext when Status was
+	// parsed successfully but could not be applied to Client.Store, e.g.
+	// because the invoice isn't tracked or the transition is illegal. A
+	// non-nil StoreError does not fail the surrounding
+	// ParseInvoiceStatuses call or the webhook delivery it backs: callers
+	// that care about individual store-sync failures should check it per
+	// status instead of relying only on the client's logger.
+	StoreError error
 }
 
 func parseInvoiceStatusAt(r *http.Request, i int) (*InvoiceStatus, error) {
@@ -289,29 +232,6 @@ func parseInvoiceStatusAt(r *http.Request, i int) (*InvoiceStatus, error) {
 	}, nil
 }
 
-func request(p url.Values) (string, error) {
-	e := encodeShiftJIS(strings.NewReader(p.Encode()))
-	res, err := http.Post(APIEndpoint, "application/x-www-form-urlencoded", e)
-	if err != nil {
-		return "", fmt.Errorf("fmart: %v", err)
-	}
-
-	if res.StatusCode != 200 {
-		return "", errors.New("fmart: server returned non 200")
-	}
-
-	body, err := ioutil.ReadAll(decodeShiftJIS(res.Body))
-	if err != nil {
-		return "", errors.New("fmart: could not read response body")
-	}
-
-	lines := strings.Split(string(body), "\n")
-	if len(lines) == 1 {
-		return lines[0], nil
-	}
-	return "", fmt.Errorf("fmart: %s", string(body))
-}
-
 func encodeShiftJIS(r io.Reader) io.Reader {
 	return transform.NewReader(r, japanese.ShiftJIS.NewEncoder())
 }
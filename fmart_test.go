@@ -1,6 +1,7 @@
 package fmart
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -22,8 +23,8 @@ func TestIssueInvoice(t *testing.T) {
 	res = "not-reached"
 	p := &IssueInvoiceParams{}
 	id, err := IssueInvoice(p)
-	if err != ErrInvalidParams {
-		t.Errorf("expected ErrInvalidParams error, got: nil")
+	if !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("expected ErrInvalidParams error, got: %v", err)
 	}
 
 	res = "invoice-1234"
@@ -75,8 +76,8 @@ func TestModifyInvoice(t *testing.T) {
 	res = "not-reached"
 	p := &ModifyInvoiceParams{}
 	err := ModifyInvoice(p)
-	if err != ErrInvalidParams {
-		t.Errorf("expected ErrInvalidParams error, got: nil")
+	if !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("expected ErrInvalidParams error, got: %v", err)
 	}
 
 	res = "invoice-1234"
@@ -0,0 +1,76 @@
+package fmart
+
+import (
+	"net/http"
+)
+
+// StatusNotificationHandler is an http.Handler for the FamilyMart deposit
+// status callback. It authenticates and parses the request via
+// Client.ParseInvoiceStatuses, fans the resulting statuses out to
+// subscribers registered on Client via Subscribe, and only acknowledges the
+// notification (via Client.AckInvoiceStatuses) once every subscriber has
+// committed, giving at-least-once delivery: FamilyMart keeps retrying the
+// callback until it sees a 200.
+//
+// Subscribers are registered on the Client rather than the handler itself,
+// so the same subscriber bus also receives statuses observed by
+// AwaitInvoiceState, letting callers block on and fan out a Store's
+// transitions without touching subscriber code.
+type StatusNotificationHandler struct {
+	// Client is used to authenticate/parse the request, fan out to
+	// subscribers, and acknowledge the request once they commit. Defaults
+	// to DefaultClient when nil.
+	Client *Client
+}
+
+// NewStatusNotificationHandler returns a handler that parses and acks
+// notifications through c. A nil c means DefaultClient.
+func NewStatusNotificationHandler(c *Client) *StatusNotificationHandler {
+	if c == nil {
+		c = DefaultClient
+	}
+	return &StatusNotificationHandler{Client: c}
+}
+
+// Subscribe registers fn on the underlying Client; see (*Client).Subscribe.
+func (h *StatusNotificationHandler) Subscribe(fn StatusSubscriber) (unsubscribe func()) {
+	return h.client().Subscribe(fn)
+}
+
+func (h *StatusNotificationHandler) client() *Client {
+	if h.Client != nil {
+		return h.Client
+	}
+	return DefaultClient
+}
+
+// ServeHTTP implements http.Handler.
+func (h *StatusNotificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c := h.client()
+
+	statuses, err := c.ParseInvoiceStatusesContext(r.Context(), r)
+	if err != nil {
+		code := http.StatusBadRequest
+		if err == ErrUnauthorizedRequest {
+			code = http.StatusUnauthorized
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	if err := c.notifySubscribers(r.Context(), statuses); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]string, len(statuses))
+	for i, s := range statuses {
+		ids[i] = s.ID
+	}
+	if err := c.AckInvoiceStatusesContext(r.Context(), ids); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,171 @@
+package fmart
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errFakeSubscriber = errors.New("fake subscriber failure")
+
+func TestStatusNotificationHandlerDelivers(t *testing.T) {
+	ackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ackServer.Close()
+
+	c := &Client{Endpoint: ackServer.URL}
+
+	h := NewStatusNotificationHandler(c)
+
+	var mu sync.Mutex
+	var got []*InvoiceStatus
+	h.Subscribe(func(ctx context.Context, s *InvoiceStatus) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, s)
+		return nil
+	})
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	res, err := ts.Client().PostForm(ts.URL, url.Values{
+		"login_user_id":     {""},
+		"login_password":    {""},
+		"number_of_notify":  {"1"},
+		"receipt_no_0000":   {"invoice-1"},
+		"status_0000":       {"1"},
+		"receipt_date_0000": {"201502082010"},
+		"payment_0000":      {"100"},
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if res.StatusCode != 200 {
+		t.Errorf("expected 200, got: %d", res.StatusCode)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if n := len(got); n != 1 {
+		t.Fatalf("expected 1 delivered status, got: %d", n)
+	}
+	if got[0].ID != "invoice-1" {
+		t.Errorf("expected invoice-1, got: %s", got[0].ID)
+	}
+}
+
+func TestStatusNotificationHandlerSubscriberFailure(t *testing.T) {
+	c := &Client{}
+	h := NewStatusNotificationHandler(c)
+
+	h.Subscribe(func(ctx context.Context, s *InvoiceStatus) error {
+		return errFakeSubscriber
+	})
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	res, err := ts.Client().PostForm(ts.URL, url.Values{
+		"login_user_id":     {""},
+		"login_password":    {""},
+		"number_of_notify":  {"1"},
+		"receipt_no_0000":   {"invoice-1"},
+		"status_0000":       {"1"},
+		"receipt_date_0000": {"201502082010"},
+		"payment_0000":      {"100"},
+	})
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if res.StatusCode != 500 {
+		t.Errorf("expected 500, got: %d", res.StatusCode)
+	}
+}
+
+func TestStatusNotificationHandlerVerifiesSignature(t *testing.T) {
+	ackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ackServer.Close()
+
+	c := &Client{Endpoint: ackServer.URL, UserID: "u", UserPassword: "p", SigningSecret: "shh"}
+	h := NewStatusNotificationHandler(c)
+
+	var got []*InvoiceStatus
+	h.Subscribe(func(ctx context.Context, s *InvoiceStatus) error {
+		got = append(got, s)
+		return nil
+	})
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	v := statusNotificationValues()
+	body := v.Encode()
+	tsHeader := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Fmart-Signature", sign("shh", tsHeader, body))
+	req.Header.Set("X-Fmart-Timestamp", tsHeader)
+
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if res.StatusCode != 200 {
+		t.Errorf("expected 200, got: %d", res.StatusCode)
+	}
+	if n := len(got); n != 1 {
+		t.Fatalf("expected 1 delivered status, got: %d", n)
+	}
+}
+
+func TestStatusNotificationHandlerRejectsTamperedSignature(t *testing.T) {
+	c := &Client{SigningSecret: "shh"}
+	h := NewStatusNotificationHandler(c)
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	v := statusNotificationValues()
+	body := v.Encode()
+	tsHeader := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Fmart-Signature", "sha256=0000000000000000000000000000000000000000000000000000000000000000")
+	req.Header.Set("X-Fmart-Timestamp", tsHeader)
+
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400, got: %d", res.StatusCode)
+	}
+}
+
+func TestStatusNotificationHandlerUnsubscribe(t *testing.T) {
+	c := &Client{}
+	h := NewStatusNotificationHandler(c)
+
+	called := false
+	unsubscribe := h.Subscribe(func(ctx context.Context, s *InvoiceStatus) error {
+		called = true
+		return nil
+	})
+	unsubscribe()
+
+	if n := len(c.snapshotSubscribers()); n != 0 {
+		t.Errorf("expected 0 subscribers, got: %d", n)
+	}
+	if called {
+		t.Errorf("expected subscriber not to be called")
+	}
+}
@@ -0,0 +1,99 @@
+package fmart
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrSignatureMissing is returned when the signature or timestamp
+	// header is absent from a callback request.
+	ErrSignatureMissing = errors.New("fmart: signature missing")
+	// ErrSignatureMismatch is returned when the computed HMAC does not
+	// match the signature header.
+	ErrSignatureMismatch = errors.New("fmart: signature mismatch")
+	// ErrTimestampSkew is returned when the timestamp header falls outside
+	// the configured skew window.
+	ErrTimestampSkew = errors.New("fmart: timestamp outside allowed skew")
+)
+
+const (
+	defaultSignatureHeader = "X-Fmart-Signature"
+	defaultTimestampHeader = "X-Fmart-Timestamp"
+	defaultSkewWindow      = 5 * time.Minute
+)
+
+func (c *Client) signatureHeader() string {
+	if c.SignatureHeader != "" {
+		return c.SignatureHeader
+	}
+	return defaultSignatureHeader
+}
+
+func (c *Client) timestampHeader() string {
+	if c.TimestampHeader != "" {
+		return c.TimestampHeader
+	}
+	return defaultTimestampHeader
+}
+
+func (c *Client) skewWindow() time.Duration {
+	if c.SkewWindow > 0 {
+		return c.SkewWindow
+	}
+	return defaultSkewWindow
+}
+
+// VerifyCallback authenticates r as a genuine FamilyMart status
+// notification, using the shared secret configured via c.SigningSecret. It
+// reads c.signatureHeader() (formatted "sha256=<hex>") and
+// c.timestampHeader(), recomputes HMAC-SHA256(SigningSecret, timestamp +
+// "." + rawBody) and compares it to the header in constant time, and
+// rejects timestamps more than c.skewWindow() away from now to prevent
+// replay.
+//
+// VerifyCallback copies r's raw body (via io.TeeReader) while calling
+// r.ParseForm itself, so the signature is checked against the bytes as
+// received; r.Body is left consumed, and r.Form holds the parsed values. It
+// must run before any code reads r.Body or calls r.FormValue. ParseInvoiceStatuses calls it automatically when
+// c.SigningSecret is set.
+func (c *Client) VerifyCallback(r *http.Request) error {
+	signature := r.Header.Get(c.signatureHeader())
+	timestamp := r.Header.Get(c.timestampHeader())
+	if signature == "" || timestamp == "" {
+		return ErrSignatureMissing
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrTimestampSkew
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > c.skewWindow() || skew < -c.skewWindow() {
+		return ErrTimestampSkew
+	}
+
+	var rawBody bytes.Buffer
+	r.Body = ioutil.NopCloser(io.TeeReader(r.Body, &rawBody))
+	if err := r.ParseForm(); err != nil {
+		return ErrInvalidRequest
+	}
+
+	mac := hmac.New(sha256.New, []byte(c.SigningSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(rawBody.Bytes())
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrSignatureMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package fmart
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(secret, timestamp, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newCallbackRequest(secret string, v url.Values, timestamp time.Time, tamperSignature bool) *http.Request {
+	body := v.Encode()
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	sig := sign(secret, ts, body)
+	if tamperSignature {
+		sig = "sha256=0000000000000000000000000000000000000000000000000000000000000000"
+	}
+
+	r, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Fmart-Signature", sig)
+	r.Header.Set("X-Fmart-Timestamp", ts)
+	return r
+}
+
+func TestClientVerifyCallback(t *testing.T) {
+	c := &Client{SigningSecret: "shh"}
+	v := url.Values{"login_user_id": {"u"}, "login_password": {"p"}}
+
+	r := newCallbackRequest("shh", v, time.Now(), false)
+	if err := c.VerifyCallback(r); err != nil {
+		t.Errorf("expected nil error, got: %v", err)
+	}
+}
+
+func TestClientVerifyCallbackMismatch(t *testing.T) {
+	c := &Client{SigningSecret: "shh"}
+	v := url.Values{"login_user_id": {"u"}, "login_password": {"p"}}
+
+	r := newCallbackRequest("shh", v, time.Now(), true)
+	if err := c.VerifyCallback(r); err != ErrSignatureMismatch {
+		t.Errorf("expected ErrSignatureMismatch, got: %v", err)
+	}
+}
+
+func TestClientVerifyCallbackMissing(t *testing.T) {
+	c := &Client{SigningSecret: "shh"}
+	r, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(""))
+	if err := c.VerifyCallback(r); err != ErrSignatureMissing {
+		t.Errorf("expected ErrSignatureMissing, got: %v", err)
+	}
+}
+
+func TestClientVerifyCallbackSkew(t *testing.T) {
+	c := &Client{SigningSecret: "shh"}
+	v := url.Values{"login_user_id": {"u"}, "login_password": {"p"}}
+
+	r := newCallbackRequest("shh", v, time.Now().Add(-time.Hour), false)
+	if err := c.VerifyCallback(r); err != ErrTimestampSkew {
+		t.Errorf("expected ErrTimestampSkew, got: %v", err)
+	}
+}
+
+// statusNotificationValues builds the form body of a single-status deposit
+// notification, as FamilyMart would POST it.
+func statusNotificationValues() url.Values {
+	return url.Values{
+		"login_user_id":     {"u"},
+		"login_password":    {"p"},
+		"number_of_notify":  {"1"},
+		"receipt_no_0000":   {"invoice-1"},
+		"status_0000":       {"1"},
+		"receipt_date_0000": {"201502082010"},
+		"payment_0000":      {"100"},
+	}
+}
+
+func TestClientParseInvoiceStatusesVerifiesSignature(t *testing.T) {
+	c := &Client{UserID: "u", UserPassword: "p", SigningSecret: "shh"}
+
+	r := newCallbackRequest("shh", statusNotificationValues(), time.Now(), false)
+	statuses, err := c.ParseInvoiceStatuses(r)
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if n := len(statuses); n != 1 {
+		t.Fatalf("expected 1 status, got: %d", n)
+	}
+	if statuses[0].ID != "invoice-1" {
+		t.Errorf("expected invoice-1, got: %s", statuses[0].ID)
+	}
+}
+
+func TestClientParseInvoiceStatusesRejectsTamperedSignature(t *testing.T) {
+	c := &Client{UserID: "u", UserPassword: "p", SigningSecret: "shh"}
+
+	r := newCallbackRequest("shh", statusNotificationValues(), time.Now(), true)
+	if _, err := c.ParseInvoiceStatuses(r); err != ErrSignatureMismatch {
+		t.Errorf("expected ErrSignatureMismatch, got: %v", err)
+	}
+}
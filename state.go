@@ -0,0 +1,183 @@
+package fmart
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrInvoiceNotFound is returned by a Store when asked about an invoice ID
+// it has no record of.
+var ErrInvoiceNotFound = errors.New("fmart: invoice not found")
+
+// InvoiceState represents the lifecycle state of a locally-tracked invoice.
+type InvoiceState int
+
+const (
+	// StateDraft is an invoice that has been prepared but not yet issued.
+	StateDraft InvoiceState = iota
+	// StateIssued is an invoice that has been issued via IssueInvoice.
+	StateIssued
+	// StateModified is an invoice that has been changed via ModifyInvoice.
+	StateModified
+	// StateCanceled is an invoice that has been canceled via CancelInvoice.
+	StateCanceled
+	// StateDepositMade is an invoice whose customer has deposited payment,
+	// which can still be canceled.
+	StateDepositMade
+	// StateDepositCanceled is an invoice whose deposit has been canceled
+	// after being made.
+	StateDepositCanceled
+	// StateDepositFinalized is an invoice whose deposit can no longer be
+	// canceled.
+	StateDepositFinalized
+)
+
+func (s InvoiceState) String() string {
+	switch s {
+	case StateDraft:
+		return "draft"
+	case StateIssued:
+		return "issued"
+	case StateModified:
+		return "modified"
+	case StateCanceled:
+		return "canceled"
+	case StateDepositMade:
+		return "deposit_made"
+	case StateDepositCanceled:
+		return "deposit_canceled"
+	case StateDepositFinalized:
+		return "deposit_finalized"
+	default:
+		return fmt.Sprintf("fmart.InvoiceState(%d)", int(s))
+	}
+}
+
+// transitions enumerates the legal (from, to) pairs between distinct states;
+// validTransition additionally allows every same-state pair. States not
+// listed as a key have no legal transitions to any other state.
+var transitions = map[InvoiceState][]InvoiceState{
+	StateDraft:       {StateIssued},
+	StateIssued:      {StateModified, StateCanceled, StateDepositMade},
+	StateModified:    {StateModified, StateCanceled, StateDepositMade},
+	StateDepositMade: {StateDepositCanceled, StateDepositFinalized},
+}
+
+// validTransition reports whether to is a legal next state from from. A
+// transition to the same state is always legal: StatusNotificationHandler
+// is at-least-once, so a duplicate delivery of a status the Store already
+// recorded is an expected no-op, not an error.
+func validTransition(from, to InvoiceState) bool {
+	if from == to {
+		return true
+	}
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError is returned when a Store transition is rejected because
+// either the invoice wasn't in the expected from state or (from, to) is not
+// a legal transition.
+type TransitionError struct {
+	ID   string
+	From InvoiceState
+	To   InvoiceState
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("fmart: invoice %s cannot transition from %s to %s", e.ID, e.From, e.To)
+}
+
+// Store persists the InvoiceState of invoices driven through a Client, so
+// callers have a single source of truth for invoice lifecycle instead of
+// manually correlating IDs with status callbacks.
+//
+// Implementations, including SQL-backed ones, must satisfy the same
+// contract as MemoryStore:
+//
+//   - Get returns ErrInvoiceNotFound for an ID with no record.
+//   - Transition is atomic with respect to concurrent callers: it must
+//     read the current state and, if it does not equal from or (from, to)
+//     is not a legal pair per the package's transition table, return a
+//     *TransitionError without modifying anything.
+type Store interface {
+	// Put unconditionally records state for id, creating or overwriting
+	// any existing record.
+	Put(id string, state InvoiceState) error
+	// Get returns the current state of id, or ErrInvoiceNotFound.
+	Get(id string) (InvoiceState, error)
+	// List returns every tracked invoice ID and its current state.
+	List() (map[string]InvoiceState, error)
+	// Transition moves id from from to to, failing with a *TransitionError
+	// if id is not currently in from or (from, to) is not legal.
+	Transition(id string, from, to InvoiceState) error
+}
+
+// MemoryStore is an in-memory Store, safe for concurrent use. It is
+// primarily intended for tests and single-process deployments; processes
+// that need invoice state to survive a restart should implement Store
+// against their own database.
+type MemoryStore struct {
+	mu     sync.Mutex
+	states map[string]InvoiceState
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]InvoiceState)}
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(id string, state InvoiceState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[id] = state
+	return nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(id string) (InvoiceState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[id]
+	if !ok {
+		return 0, ErrInvoiceNotFound
+	}
+	return state, nil
+}
+
+// List implements Store.
+func (s *MemoryStore) List() (map[string]InvoiceState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make(map[string]InvoiceState, len(s.states))
+	for id, state := range s.states {
+		states[id] = state
+	}
+	return states, nil
+}
+
+// Transition implements Store.
+func (s *MemoryStore) Transition(id string, from, to InvoiceState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.states[id]
+	if !ok {
+		return ErrInvoiceNotFound
+	}
+	if cur != from || !validTransition(from, to) {
+		return &TransitionError{ID: id, From: cur, To: to}
+	}
+
+	s.states[id] = to
+	return nil
+}
@@ -0,0 +1,87 @@
+package fmart
+
+import "testing"
+
+func TestMemoryStorePutGet(t *testing.T) {
+	s := NewMemoryStore()
+
+	if _, err := s.Get("invoice-1"); err != ErrInvoiceNotFound {
+		t.Errorf("expected ErrInvoiceNotFound, got: %v", err)
+	}
+
+	if err := s.Put("invoice-1", StateIssued); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+
+	state, err := s.Get("invoice-1")
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if state != StateIssued {
+		t.Errorf("expected StateIssued, got: %v", state)
+	}
+}
+
+func TestMemoryStoreTransition(t *testing.T) {
+	s := NewMemoryStore()
+	s.Put("invoice-1", StateIssued)
+
+	if err := s.Transition("invoice-1", StateIssued, StateModified); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+
+	state, _ := s.Get("invoice-1")
+	if state != StateModified {
+		t.Errorf("expected StateModified, got: %v", state)
+	}
+}
+
+func TestMemoryStoreTransitionIllegal(t *testing.T) {
+	s := NewMemoryStore()
+	s.Put("invoice-1", StateDepositFinalized)
+
+	err := s.Transition("invoice-1", StateDepositFinalized, StateModified)
+	if _, ok := err.(*TransitionError); !ok {
+		t.Errorf("expected *TransitionError, got: %v", err)
+	}
+}
+
+func TestMemoryStoreTransitionDuplicate(t *testing.T) {
+	s := NewMemoryStore()
+	s.Put("invoice-1", StateDepositFinalized)
+
+	// A duplicate at-least-once delivery of a status the Store already
+	// recorded must be a no-op, not a *TransitionError.
+	if err := s.Transition("invoice-1", StateDepositFinalized, StateDepositFinalized); err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+
+	state, _ := s.Get("invoice-1")
+	if state != StateDepositFinalized {
+		t.Errorf("expected StateDepositFinalized, got: %v", state)
+	}
+}
+
+func TestMemoryStoreTransitionWrongFrom(t *testing.T) {
+	s := NewMemoryStore()
+	s.Put("invoice-1", StateModified)
+
+	err := s.Transition("invoice-1", StateIssued, StateCanceled)
+	if _, ok := err.(*TransitionError); !ok {
+		t.Errorf("expected *TransitionError, got: %v", err)
+	}
+}
+
+func TestMemoryStoreList(t *testing.T) {
+	s := NewMemoryStore()
+	s.Put("invoice-1", StateIssued)
+	s.Put("invoice-2", StateCanceled)
+
+	states, err := s.List()
+	if err != nil {
+		t.Fatalf("expected nil error, got: %v", err)
+	}
+	if n := len(states); n != 2 {
+		t.Errorf("expected 2 states, got: %d", n)
+	}
+}